@@ -13,8 +13,9 @@ import (
 )
 
 type AppConfig struct {
-	KiroServerURL string `json:"kiro_server_url"`
-	BackendURL    string `json:"backend_url"`
+	KiroServerURL string            `json:"kiro_server_url"`
+	BackendURL    string            `json:"backend_url"`
+	Tokens        map[string]string `json:"tokens,omitempty"`
 }
 
 func configDir() (string, error) {
@@ -44,10 +45,32 @@ func loadConfig() (*AppConfig, error) {
 		return nil, err
 	}
 
+	if isEncryptedConfig(data) {
+		key, err := getOrCreateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load config encryption key: %w", err)
+		}
+		plaintext, err := decryptConfigBytes(key, data)
+		if err != nil {
+			return nil, err
+		}
+
+		var cfg AppConfig
+		if err := json.Unmarshal(plaintext, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse config: %w", err)
+		}
+		return &cfg, nil
+	}
+
+	// Legacy plaintext config.json from before encryption at rest: parse it,
+	// then transparently migrate it to the encrypted format on disk.
 	var cfg AppConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	if err := saveConfig(&cfg); err != nil {
+		fmt.Printf("Warning: failed to migrate plaintext config to encrypted format: %v\n", err)
+	}
 
 	return &cfg, nil
 }
@@ -82,13 +105,22 @@ func saveConfig(cfg *AppConfig) error {
 	normalized := &AppConfig{
 		KiroServerURL: kiroURL,
 		BackendURL:    backendURL,
+		Tokens:        cfg.Tokens,
 	}
 
-	data, err := json.MarshalIndent(normalized, "", "  ")
+	plaintext, err := json.Marshal(normalized)
 	if err != nil {
 		return fmt.Errorf("failed to serialize config: %w", err)
 	}
-	data = append(data, '\n')
+
+	key, err := getOrCreateKey()
+	if err != nil {
+		return fmt.Errorf("failed to get config encryption key: %w", err)
+	}
+	data, err := encryptConfigBytes(key, plaintext)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt config: %w", err)
+	}
 
 	tmpPath := path + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
@@ -102,6 +134,21 @@ func saveConfig(cfg *AppConfig) error {
 	return nil
 }
 
+// rotateConfigKey re-encrypts config.json under a freshly generated key,
+// discarding whatever key (keyring entry or key file) protected it before.
+func rotateConfigKey() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return fmt.Errorf("failed to load existing config: %w", err)
+	}
+
+	if err := deleteStoredKey(); err != nil {
+		return fmt.Errorf("failed to discard old config key: %w", err)
+	}
+
+	return saveConfig(cfg)
+}
+
 func hasCompleteUserConfig() bool {
 	if _, err := resolveKiroServerURL(); err != nil {
 		return false
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// configMagic prefixes an encrypted config file so loadConfig can tell it
+// apart from a legacy plaintext config.json and migrate transparently.
+var configMagic = []byte("ANTIv1\x00")
+
+const configKeySize = 32 // AES-256
+
+func isEncryptedConfig(data []byte) bool {
+	return len(data) >= len(configMagic) && bytes.Equal(data[:len(configMagic)], configMagic)
+}
+
+// encryptConfigBytes seals plaintext with AES-GCM under key, prefixing the
+// result with configMagic and a random nonce.
+func encryptConfigBytes(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, len(configMagic)+len(nonce)+len(plaintext)+gcm.Overhead())
+	out = append(out, configMagic...)
+	out = append(out, nonce...)
+	out = gcm.Seal(out, nonce, plaintext, nil)
+	return out, nil
+}
+
+// decryptConfigBytes reverses encryptConfigBytes. data must start with
+// configMagic, as reported by isEncryptedConfig.
+func decryptConfigBytes(key, data []byte) ([]byte, error) {
+	if !isEncryptedConfig(data) {
+		return nil, errors.New("data is not an antihook encrypted config")
+	}
+	body := data[len(configMagic):]
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+
+	if len(body) < gcm.NonceSize() {
+		return nil, errors.New("encrypted config is truncated")
+	}
+	nonce, ciphertext := body[:gcm.NonceSize()], body[gcm.NonceSize():]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt config (wrong or rotated key?): %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keyFilePath is the fallback location for the config encryption key when no
+// OS keyring is available. It lives next to config.json but is never read or
+// written by the JSON config code directly.
+func keyFilePath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "key"), nil
+}
+
+// fileBackedKey loads the key from keyFilePath, generating and persisting a
+// fresh one on first use. It's the last resort when the platform has no
+// keyring, or the keyring is unavailable (locked, missing D-Bus, etc).
+func fileBackedKey() ([]byte, error) {
+	path, err := keyFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := os.ReadFile(path); err == nil {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err == nil && len(key) == configKeySize {
+			return key, nil
+		}
+	}
+
+	key := make([]byte, configKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate config key: %w", err)
+	}
+
+	dir, err := configDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create config dir: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)), 0600); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+
+	return key, nil
+}
+
+func deleteKeyFile() error {
+	path, err := keyFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
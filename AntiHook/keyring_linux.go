@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretToolService/secretToolAccount identify our secret in the Secret
+// Service keyring (gnome-keyring, KWallet's libsecret shim, etc), which we
+// talk to via secret-tool rather than the D-Bus wire protocol directly.
+const (
+	secretToolService = "antihook"
+	secretToolAccount = "config-key"
+)
+
+// getOrCreateKey returns the config encryption key, preferring the desktop
+// Secret Service keyring (via secret-tool) and falling back to a key file
+// under ~/.config/antihook when no keyring is reachable.
+func getOrCreateKey() ([]byte, error) {
+	if key, err := secretToolLookupKey(); err == nil {
+		return key, nil
+	}
+
+	if _, err := exec.LookPath("secret-tool"); err == nil {
+		key := make([]byte, configKeySize)
+		if _, err := rand.Read(key); err == nil {
+			if err := secretToolStoreKey(key); err == nil {
+				return key, nil
+			}
+		}
+	}
+
+	return fileBackedKey()
+}
+
+// deleteStoredKey removes the key from both the keyring and the fallback key
+// file, so the next getOrCreateKey call generates and stores a fresh one.
+func deleteStoredKey() error {
+	if _, err := exec.LookPath("secret-tool"); err == nil {
+		_ = exec.Command("secret-tool", "clear", "service", secretToolService, "account", secretToolAccount).Run()
+	}
+	return deleteKeyFile()
+}
+
+func secretToolLookupKey() ([]byte, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command("secret-tool", "lookup", "service", secretToolService, "account", secretToolAccount).Output()
+	if err != nil {
+		return nil, fmt.Errorf("secret-tool lookup failed: %w", err)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(out)))
+	if err != nil || len(key) != configKeySize {
+		return nil, fmt.Errorf("keyring returned invalid key material")
+	}
+	return key, nil
+}
+
+func secretToolStoreKey(key []byte) error {
+	cmd := exec.Command("secret-tool", "store", "--label=AntiHook config encryption key",
+		"service", secretToolService, "account", secretToolAccount)
+	cmd.Stdin = strings.NewReader(base64.StdEncoding.EncodeToString(key))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool store failed: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
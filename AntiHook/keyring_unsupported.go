@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// getOrCreateKey falls back to a key file on platforms where antihook has no
+// keyring integration yet.
+func getOrCreateKey() ([]byte, error) {
+	return fileBackedKey()
+}
+
+func deleteStoredKey() error {
+	return deleteKeyFile()
+}
@@ -0,0 +1,89 @@
+// Package logging provides antihook's structured logger: a rotating,
+// size-capped file sink built on log/slog, with a redaction pass that scrubs
+// OAuth secrets (authorization codes, state nonces, identity tokens, bearer
+// tokens) out of every record before it touches disk.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Options configures New. Path is the log file to write to; the zero values
+// for MaxBytes/MaxBackups fall back to sensible defaults (5 MB, 3 backups).
+type Options struct {
+	Path       string
+	Level      slog.Level
+	JSON       bool
+	MaxBytes   int64
+	MaxBackups int
+}
+
+const (
+	defaultMaxBytes   = 5 * 1024 * 1024
+	defaultMaxBackups = 3
+)
+
+// New builds a *slog.Logger that writes redacted, rotating log files at
+// opts.Path. The returned io.Closer flushes and closes the underlying file
+// and should be closed before the process exits.
+func New(opts Options) (*slog.Logger, io.Closer, error) {
+	maxBytes := opts.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultMaxBytes
+	}
+	maxBackups := opts.MaxBackups
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+
+	rotator, err := newRotatingWriter(opts.Path, maxBytes, maxBackups)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	writer := &redactingWriter{w: rotator}
+
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	if opts.JSON {
+		handler = slog.NewJSONHandler(writer, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(writer, handlerOpts)
+	}
+
+	return slog.New(handler), rotator, nil
+}
+
+// ParseLevel maps the --log-level flag value to a slog.Level, defaulting to
+// Info for anything it doesn't recognize.
+func ParseLevel(s string) slog.Level {
+	switch s {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// redactingWriter scrubs secrets out of every write before handing it to the
+// underlying writer, so handler formatting (JSON or text) never has to know
+// about redaction.
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	redacted := Redact(string(p))
+	if _, err := rw.w.Write([]byte(redacted)); err != nil {
+		return 0, err
+	}
+	// Report the original length so slog's handler doesn't treat a shorter
+	// redacted write as a short write error.
+	return len(p), nil
+}
@@ -0,0 +1,26 @@
+package logging
+
+import "regexp"
+
+// redactions scrub OAuth secrets out of log lines: authorization codes,
+// state nonces, the shared-secret "identity" query param antihook's own
+// anti:// URLs carry, and any Authorization: Bearer header value.
+var redactions = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	{regexp.MustCompile(`(?i)(code=)[^&\s"]+`), "${1}REDACTED"},
+	{regexp.MustCompile(`(?i)(state=)[^&\s"]+`), "${1}REDACTED"},
+	{regexp.MustCompile(`(?i)(identity=)[^&\s"]+`), "${1}REDACTED"},
+	{regexp.MustCompile(`(?i)(bearer\s+)\S+`), "${1}REDACTED"},
+}
+
+// Redact scrubs every known secret pattern out of s, returning the sanitized
+// string. It's safe to call on arbitrary log content, including whole
+// request bodies and URLs.
+func Redact(s string) string {
+	for _, r := range redactions {
+		s = r.pattern.ReplaceAllString(s, r.replace)
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package logging
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// rotatingWriter is an io.WriteCloser that rotates its target file once it
+// crosses maxBytes, gzipping the rolled-over file and keeping at most
+// maxBackups of them (path.1.gz is the newest, path.N.gz the oldest).
+type rotatingWriter struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func newRotatingWriter(path string, maxBytes int64, maxBackups int) (*rotatingWriter, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &rotatingWriter{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+		file:       file,
+		size:       info.Size(),
+	}, nil
+}
+
+func (w *rotatingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.size > 0 && w.size+int64(len(p)) > w.maxBytes {
+		if err := w.rotate(); err != nil {
+			// Rotation failing shouldn't drop the log line; keep writing to
+			// the (now oversized) current file instead.
+			fmt.Fprintf(os.Stderr, "antihook: log rotation failed: %v\n", err)
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotatingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+
+	for i := w.maxBackups; i >= 1; i-- {
+		src := w.backupPath(i)
+		if i == w.maxBackups {
+			_ = os.Remove(src)
+			continue
+		}
+		_ = os.Rename(src, w.backupPath(i+1))
+	}
+
+	if err := gzipFile(w.path, w.backupPath(1)); err != nil {
+		return err
+	}
+	if err := os.Remove(w.path); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+	return nil
+}
+
+func (w *rotatingWriter) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d.gz", w.path, n)
+}
+
+func gzipFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		gz.Close()
+		return err
+	}
+	return gz.Close()
+}
@@ -3,10 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
@@ -15,6 +20,7 @@ import (
 	"sync"
 	"time"
 
+	"antihook/logging"
 	protocolRegistry "antihook/registry"
 )
 
@@ -22,7 +28,6 @@ const (
 	ProtocolDescription     = "Kiro Protocol Handler"
 	AntiProtocolDescription = "Anti Protocol Handler"
 	TargetDirName           = "Antihub"
-	OAuthCallbackPort       = 42532
 )
 
 // 这些变量可以在编译时通过 -ldflags 注入
@@ -43,12 +48,61 @@ func init() {
 	}
 }
 
+// log is antihook's process-wide logger, set up in main() once flags are
+// parsed. Before that it's a no-op discard logger so early helpers never
+// nil-check it.
+var log = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+// setupLogging points log at the rotating kiro.log sink and returns a closer
+// to flush it before the process exits.
+func setupLogging(level slog.Level, jsonFormat bool) (io.Closer, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get home directory: %w", err)
+	}
+	logPath := filepath.Join(homeDir, ".config", "antihook", "kiro.log")
+
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	logger, closer, err := logging.New(logging.Options{
+		Path:  logPath,
+		Level: level,
+		JSON:  jsonFormat,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log = logger
+	return closer, nil
+}
+
 func main() {
 	recoverFlag := flag.Bool("recover", false, "Restore original Kiro protocol handler")
 	configFlag := flag.Bool("config", false, "Run configuration wizard and exit")
 	printConfigPathFlag := flag.Bool("print-config-path", false, "Print config file path and exit")
+	rotateKeyFlag := flag.Bool("rotate-key", false, "Re-encrypt config.json with a freshly generated key")
+	logLevelFlag := flag.String("log-level", "info", "Log level: debug, info, warn, error")
+	logJSONFlag := flag.Bool("log-json", false, "Write logs as JSON instead of plain text")
 	flag.Parse()
 
+	if closer, err := setupLogging(logging.ParseLevel(*logLevelFlag), *logJSONFlag); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: failed to set up logging:", err)
+	} else {
+		defer closer.Close()
+	}
+
+	if *rotateKeyFlag {
+		if err := rotateConfigKey(); err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to rotate config key:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Config key rotated.")
+		return
+	}
+
 	if *recoverFlag {
 		if err := recoverOriginal(); err != nil {
 			showMessageBox("Error", "Recovery failed: "+err.Error(), 0x10)
@@ -144,27 +198,92 @@ func install() error {
 		Description: ProtocolDescription,
 	}
 
-	if err := kiroHandler.Register(); err != nil {
-		return fmt.Errorf("failed to register kiro protocol: %w", err)
-	}
-
 	antiHandler := &protocolRegistry.ProtocolHandler{
 		Protocol:    protocolRegistry.AntiProtocolName,
 		ExePath:     targetPath,
 		Description: AntiProtocolDescription,
 	}
 
+	// Snapshot whatever handler was previously registered so --recover can
+	// put it back; a failed snapshot just means recover will clear ours
+	// instead of restoring a prior one.
+	kiroBackup, _ := kiroHandler.Backup()
+	antiBackup, _ := antiHandler.Backup()
+	if err := saveRegistryBackup(kiroBackup, antiBackup); err != nil {
+		log.Warn("failed to save registry backup", "error", err)
+	}
+
+	if err := kiroHandler.Register(); err != nil {
+		return fmt.Errorf("failed to register kiro protocol: %w", err)
+	}
+
 	if err := antiHandler.Register(); err != nil {
 		return fmt.Errorf("failed to register anti protocol: %w", err)
 	}
 
 	if err := addToPath(targetDir); err != nil {
-		fmt.Printf("Warning: failed to add to PATH: %v\n", err)
+		log.Warn("failed to add to PATH", "error", err)
 	}
 
 	return nil
 }
 
+// registryBackup is what install() persists so a later --recover can put the
+// previously registered protocol handlers back in place.
+type registryBackup struct {
+	Kiro map[string]string `json:"kiro"`
+	Anti map[string]string `json:"anti"`
+}
+
+func registryBackupPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "registry-backup.json"), nil
+}
+
+func saveRegistryBackup(kiro, anti map[string]string) error {
+	dir, err := configDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	path, err := registryBackupPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(&registryBackup{Kiro: kiro, Anti: anti}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize registry backup: %w", err)
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
+
+func loadRegistryBackup() (*registryBackup, error) {
+	path, err := registryBackupPath()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var backup registryBackup
+	if err := json.Unmarshal(data, &backup); err != nil {
+		return nil, fmt.Errorf("failed to parse registry backup: %w", err)
+	}
+
+	return &backup, nil
+}
+
 func copyFile(src, dst string) error {
 	srcFile, err := os.Open(src)
 	if err != nil {
@@ -186,46 +305,19 @@ func copyFile(src, dst string) error {
 }
 
 func handleProtocolCall(rawURL string) {
-	// 创建日志文件
-	homeDir, _ := os.UserHomeDir()
-	logFile, err := os.OpenFile(filepath.Join(homeDir, ".config", "antihook", "kiro.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err == nil {
-		defer logFile.Close()
-		logFile.WriteString(fmt.Sprintf("\n=== %s ===\n", time.Now().Format("2006-01-02 15:04:05")))
-		logFile.WriteString(fmt.Sprintf("Received kiro:// callback: %s\n", rawURL))
-	}
-
-	// 记录接收到的回调 URL
-	fmt.Printf("Received kiro:// callback: %s\n", rawURL)
-
-	// 移除了 "Logging in..." 弹框
+	log.Info("received kiro callback", "url", rawURL)
 
 	if err := postCallback(rawURL); err != nil {
-		errMsg := fmt.Sprintf("Login failed: %v\n", err)
-		fmt.Printf(errMsg)
-		if logFile != nil {
-			logFile.WriteString(errMsg)
-		}
+		log.Error("kiro login failed", "error", err)
 		showMessageBox("Error", "Login failed: "+err.Error(), 0x10)
 		return
 	}
 
-	successMsg := "Login successful!\n"
-	fmt.Printf(successMsg)
-	if logFile != nil {
-		logFile.WriteString(successMsg)
-	}
+	log.Info("kiro login successful")
 	showMessageBox("Success", "Login successful!", 0x40)
 }
 
 func postCallback(callbackURL string) error {
-	// 打开日志文件
-	homeDir, _ := os.UserHomeDir()
-	logFile, _ := os.OpenFile(filepath.Join(homeDir, ".config", "antihook", "kiro.log"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if logFile != nil {
-		defer logFile.Close()
-	}
-
 	requestBody := map[string]string{
 		"callback_url": callbackURL,
 	}
@@ -241,19 +333,7 @@ func postCallback(callbackURL string) error {
 	}
 
 	apiURL := serverURL + "/api/kiro/oauth/callback"
-
-	// 记录详细的请求信息
-	logMsg := fmt.Sprintf("Posting to: %s\n", apiURL)
-	fmt.Printf(logMsg)
-	if logFile != nil {
-		logFile.WriteString(logMsg)
-	}
-
-	logMsg = fmt.Sprintf("Request body: %s\n", string(jsonData))
-	fmt.Printf(logMsg)
-	if logFile != nil {
-		logFile.WriteString(logMsg)
-	}
+	log.Debug("posting kiro oauth callback", "url", apiURL, "body", string(jsonData))
 
 	resp, err := http.Post(
 		apiURL,
@@ -261,27 +341,13 @@ func postCallback(callbackURL string) error {
 		bytes.NewBuffer(jsonData),
 	)
 	if err != nil {
-		errMsg := fmt.Sprintf("HTTP request failed: %v\n", err)
-		if logFile != nil {
-			logFile.WriteString(errMsg)
-		}
+		log.Error("kiro oauth callback request failed", "error", err)
 		return fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// 读取响应内容
 	body, _ := io.ReadAll(resp.Body)
-	logMsg = fmt.Sprintf("Response status: %d\n", resp.StatusCode)
-	fmt.Printf(logMsg)
-	if logFile != nil {
-		logFile.WriteString(logMsg)
-	}
-
-	logMsg = fmt.Sprintf("Response body: %s\n", string(body))
-	fmt.Printf(logMsg)
-	if logFile != nil {
-		logFile.WriteString(logMsg)
-	}
+	log.Debug("kiro oauth callback response", "status", resp.StatusCode, "body", string(body))
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("server returned error: %d, %s", resp.StatusCode, string(body))
@@ -361,8 +427,27 @@ func handleAntiProtocolCall(rawURL string) {
 		return
 	}
 
-	authResp, err := requestOAuthAuthorize(serverURL, params.Bearer, params.IsShared)
+	// Bind before requesting authorization so the real, kernel-assigned port
+	// can be templated into the redirect URI. This also sidesteps the port
+	// conflicts a fixed, hard-coded callback port would hit on shared machines.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		showMessageBox("Error", "Failed to start callback server: "+err.Error(), 0x10)
+		return
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/oauth-callback", port)
+
+	state, err := generateOAuthState()
+	if err != nil {
+		listener.Close()
+		showMessageBox("Error", "Failed to generate OAuth state: "+err.Error(), 0x10)
+		return
+	}
+
+	authResp, err := requestOAuthAuthorize(serverURL, params.Bearer, params.IsShared, redirectURI, state)
 	if err != nil {
+		listener.Close()
 		showMessageBox("Error", "OAuth authorization failed: "+err.Error(), 0x10)
 		return
 	}
@@ -375,7 +460,7 @@ func handleAntiProtocolCall(rawURL string) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(authResp.Data.ExpiresIn)*time.Second)
 	defer cancel()
 
-	server := startOAuthCallbackServer(ctx, callbackChan, errChan, &wg)
+	server := startOAuthCallbackServer(listener, port, state, callbackChan, errChan, &wg)
 
 	if err := openBrowser(authResp.Data.AuthURL); err != nil {
 		showMessageBox("Error", "Failed to open browser: "+err.Error(), 0x10)
@@ -388,6 +473,7 @@ func handleAntiProtocolCall(rawURL string) {
 		if err := postOAuthCallbackManual(serverURL, params.Bearer, callbackURL); err != nil {
 			showMessageBox("Error", "Failed to complete OAuth: "+err.Error(), 0x10)
 		} else {
+			saveAntiBearerToken(params.Bearer)
 			showMessageBox("Success", "Login successful!", 0x40)
 		}
 	case err := <-errChan:
@@ -400,11 +486,31 @@ func handleAntiProtocolCall(rawURL string) {
 	wg.Wait()
 }
 
-func requestOAuthAuthorize(serverURL, bearer string, isShared int) (*OAuthAuthorizeResponse, error) {
+// saveAntiBearerToken persists the bearer token used for an anti:// login so
+// it can be reused without re-authenticating. Failures are non-fatal: the
+// login itself already succeeded.
+func saveAntiBearerToken(bearer string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = &AppConfig{}
+	}
+	if cfg.Tokens == nil {
+		cfg.Tokens = make(map[string]string)
+	}
+	cfg.Tokens[protocolRegistry.AntiProtocolName] = bearer
+
+	if err := saveConfig(cfg); err != nil {
+		log.Warn("failed to save bearer token", "error", err)
+	}
+}
+
+func requestOAuthAuthorize(serverURL, bearer string, isShared int, redirectURI, state string) (*OAuthAuthorizeResponse, error) {
 	apiURL := serverURL + "/api/plugin-api/oauth/authorize"
 
-	requestBody := map[string]int{
-		"is_shared": isShared,
+	requestBody := map[string]interface{}{
+		"is_shared":    isShared,
+		"redirect_uri": redirectURI,
+		"state":        state,
 	}
 
 	jsonData, err := json.Marshal(requestBody)
@@ -448,15 +554,61 @@ func requestOAuthAuthorize(serverURL, bearer string, isShared int) (*OAuthAuthor
 	return &authResp, nil
 }
 
-func startOAuthCallbackServer(ctx context.Context, callbackChan chan<- string, errChan chan<- error, wg *sync.WaitGroup) *http.Server {
-	mux := http.NewServeMux()
+// generateOAuthState returns a cryptographically random, URL-safe nonce
+// bound to a single anti:// invocation, per RFC 8252's loopback guidance.
+func generateOAuthState() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
 
+// isLoopbackOrEmpty reports whether an Origin/Referer header value is
+// either absent (native browser navigations often send none) or points at
+// loopback, rejecting anything else to block DNS-rebinding attacks against
+// the callback server.
+func isLoopbackOrEmpty(header string) bool {
+	if header == "" {
+		return true
+	}
+	u, err := url.Parse(header)
+	if err != nil {
+		return false
+	}
+	switch u.Hostname() {
+	case "127.0.0.1", "localhost", "::1":
+		return true
+	default:
+		return false
+	}
+}
+
+// startOAuthCallbackServer serves the loopback OAuth redirect on an
+// already-bound listener. It rejects callbacks with a mismatched state
+// (constant-time comparison), a non-loopback Origin/Referer, or a Host
+// header other than the exact loopback address antihook is listening on.
+func startOAuthCallbackServer(listener net.Listener, port int, expectedState string, callbackChan chan<- string, errChan chan<- error, wg *sync.WaitGroup) *http.Server {
+	expectedHost := fmt.Sprintf("127.0.0.1:%d", port)
+
+	mux := http.NewServeMux()
 	mux.HandleFunc("/oauth-callback", func(w http.ResponseWriter, r *http.Request) {
-		// 构造完整的回调 URL，包含所有查询参数
-		callbackURL := fmt.Sprintf("http://localhost:%d%s", OAuthCallbackPort, r.URL.RequestURI())
+		if !isLoopbackOrEmpty(r.Header.Get("Origin")) || !isLoopbackOrEmpty(r.Header.Get("Referer")) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Host != expectedHost {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("state")), []byte(expectedState)) != 1 {
+			http.Error(w, "invalid state", http.StatusForbidden)
+			return
+		}
 
-		// 记录日志（可选，用于调试）
-		fmt.Printf("Received OAuth callback: %s\n", callbackURL)
+		callbackURL := fmt.Sprintf("http://127.0.0.1:%d%s", port, r.URL.RequestURI())
+
+		log.Debug("received oauth callback", "url", callbackURL)
 
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
@@ -500,23 +652,20 @@ func startOAuthCallbackServer(ctx context.Context, callbackChan chan<- string, e
 		}
 	})
 
-	server := &http.Server{
-		Addr:    fmt.Sprintf(":%d", OAuthCallbackPort),
-		Handler: mux,
-	}
+	server := &http.Server{Handler: mux}
 
+	ready := make(chan struct{})
 	go func() {
 		defer wg.Done()
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		close(ready)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 			select {
 			case errChan <- err:
 			default:
 			}
 		}
 	}()
-
-	// 等待更长时间确保服务器完全启动
-	time.Sleep(500 * time.Millisecond)
+	<-ready
 
 	return server
 }
@@ -0,0 +1,248 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	protocolRegistry "antihook/registry"
+)
+
+func showMessageBox(title, message string, flags uint) {
+	_ = flags
+	fmt.Printf("%s: %s\n", title, message)
+}
+
+const (
+	pathBlockStartMarker = "# >>> antihook >>>"
+	pathBlockEndMarker   = "# <<< antihook <<<"
+)
+
+// shellRCFile returns the rc file antihook should edit for the user's shell
+// (detected from $SHELL), and whether that file uses fish syntax. Unknown or
+// unset shells fall back to bash's rc file.
+func shellRCFile() (path string, isFish bool, err error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	shell := filepath.Base(os.Getenv("SHELL"))
+	switch {
+	case strings.Contains(shell, "fish"):
+		return filepath.Join(homeDir, ".config", "fish", "config.fish"), true, nil
+	case strings.Contains(shell, "zsh"):
+		return filepath.Join(homeDir, ".zshrc"), false, nil
+	default:
+		return filepath.Join(homeDir, ".bashrc"), false, nil
+	}
+}
+
+// allShellRCFiles lists every rc file addToPath could have written to,
+// regardless of the shell active right now, so removeFromPath can clean up
+// whichever one install() actually touched.
+func allShellRCFiles(homeDir string) []string {
+	return []string{
+		filepath.Join(homeDir, ".bashrc"),
+		filepath.Join(homeDir, ".zshrc"),
+		filepath.Join(homeDir, ".config", "fish", "config.fish"),
+	}
+}
+
+func pathExportLine(dir string, isFish bool) string {
+	if isFish {
+		return fmt.Sprintf("fish_add_path %s", dir)
+	}
+	return fmt.Sprintf(`export PATH="%s:$PATH"`, dir)
+}
+
+// stripPathBlock removes a previously-written antihook marker block from
+// content, if present. The other lines are kept as they are, except that
+// trailing blank lines are dropped and the result ends in a single newline.
+func stripPathBlock(content string) string {
+	if !strings.Contains(content, pathBlockStartMarker) {
+		return content
+	}
+
+	lines := strings.Split(content, "\n")
+	out := make([]string, 0, len(lines))
+	inBlock := false
+	for _, line := range lines {
+		switch strings.TrimSpace(line) {
+		case pathBlockStartMarker:
+			inBlock = true
+			continue
+		case pathBlockEndMarker:
+			inBlock = false
+			continue
+		}
+		if inBlock {
+			continue
+		}
+		out = append(out, line)
+	}
+
+	for len(out) > 0 && strings.TrimSpace(out[len(out)-1]) == "" {
+		out = out[:len(out)-1]
+	}
+
+	result := strings.Join(out, "\n")
+	if result != "" {
+		result += "\n"
+	}
+	return result
+}
+
+// addToPath appends dir to the user's shell PATH by writing an idempotent,
+// marker-delimited block to their rc file. Re-running it (e.g. across
+// repeated installs) replaces the previous block instead of duplicating it.
+func addToPath(dir string) error {
+	path, isFish, err := shellRCFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create rc file directory: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	content := stripPathBlock(string(existing))
+	if content != "" {
+		content += "\n"
+	}
+	content += pathBlockStartMarker + "\n" + pathExportLine(dir, isFish) + "\n" + pathBlockEndMarker + "\n"
+
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// removeFromPath undoes addToPath, stripping the antihook marker block from
+// whichever rc file it was written to. Called from --recover.
+func removeFromPath() error {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+
+	var firstErr error
+	for _, path := range allShellRCFiles(homeDir) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if !os.IsNotExist(err) && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !strings.Contains(string(data), pathBlockStartMarker) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(stripPathBlock(string(data))), 0644); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// isWSL reports whether we're running under Windows Subsystem for Linux, by
+// checking for the "Microsoft" marker WSL's kernel puts in /proc/version.
+func isWSL() bool {
+	data, err := os.ReadFile("/proc/version")
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(string(data)), "microsoft")
+}
+
+// openBrowser opens url in the user's default browser. It tries, in order:
+// cmd.exe (when running under WSL), xdg-open, sensible-browser, then the
+// $BROWSER environment variable. If nothing works it prints the URL so the
+// user can open it manually instead of hanging silently.
+func openBrowser(url string) error {
+	var attempts []string
+	var tried []string
+
+	if isWSL() {
+		attempts = append(attempts, "cmd.exe")
+	}
+	attempts = append(attempts, "xdg-open", "sensible-browser")
+	if browser := strings.TrimSpace(os.Getenv("BROWSER")); browser != "" {
+		attempts = append(attempts, browser)
+	}
+
+	for _, name := range attempts {
+		var cmd *exec.Cmd
+		if name == "cmd.exe" {
+			cmd = exec.Command("cmd.exe", "/c", "start", url)
+		} else {
+			path, err := exec.LookPath(name)
+			if err != nil {
+				tried = append(tried, fmt.Sprintf("%s (not found)", name))
+				continue
+			}
+			cmd = exec.Command(path, url)
+		}
+
+		if err := cmd.Start(); err != nil {
+			tried = append(tried, fmt.Sprintf("%s (%v)", name, err))
+			continue
+		}
+		go cmd.Wait()
+		return nil
+	}
+
+	fmt.Printf("Please open this URL in your browser: %s\n", url)
+	return fmt.Errorf("failed to open browser, tried: %s", strings.Join(tried, ", "))
+}
+
+// recoverOriginal restores whichever protocol handler was registered before
+// install() ran, using the snapshot install() saved via saveRegistryBackup.
+func recoverOriginal() error {
+	backup, err := loadRegistryBackup()
+	if err != nil {
+		return fmt.Errorf("no registry backup found, nothing to recover: %w", err)
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get home directory: %w", err)
+	}
+	targetPath := filepath.Join(homeDir, ".local", "bin", TargetDirName, "antihook")
+
+	kiroHandler := &protocolRegistry.ProtocolHandler{
+		Protocol:    protocolRegistry.ProtocolName,
+		ExePath:     targetPath,
+		Description: ProtocolDescription,
+	}
+	if err := kiroHandler.Restore(backup.Kiro); err != nil {
+		return fmt.Errorf("failed to restore kiro protocol: %w", err)
+	}
+
+	antiHandler := &protocolRegistry.ProtocolHandler{
+		Protocol:    protocolRegistry.AntiProtocolName,
+		ExePath:     targetPath,
+		Description: AntiProtocolDescription,
+	}
+	if err := antiHandler.Restore(backup.Anti); err != nil {
+		return fmt.Errorf("failed to restore anti protocol: %w", err)
+	}
+
+	if err := removeFromPath(); err != nil {
+		fmt.Printf("Warning: failed to remove from PATH: %v\n", err)
+	}
+
+	if path, err := registryBackupPath(); err == nil {
+		_ = os.Remove(path)
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+//go:build linux
+// +build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddToPathIsIdempotent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/bash")
+
+	dir := filepath.Join(home, ".local", "bin", "Antihub")
+
+	if err := addToPath(dir); err != nil {
+		t.Fatalf("addToPath (1st call) failed: %v", err)
+	}
+	if err := addToPath(dir); err != nil {
+		t.Fatalf("addToPath (2nd call) failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if err != nil {
+		t.Fatalf("failed to read .bashrc: %v", err)
+	}
+	content := string(data)
+
+	if n := strings.Count(content, pathBlockStartMarker); n != 1 {
+		t.Fatalf("expected exactly one marker block after repeated installs, got %d", n)
+	}
+	if !strings.Contains(content, dir) {
+		t.Fatalf("expected .bashrc to contain %q, got:\n%s", dir, content)
+	}
+}
+
+func TestAddToPathFishUsesFishAddPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/usr/bin/fish")
+
+	dir := filepath.Join(home, ".local", "bin", "Antihub")
+	if err := addToPath(dir); err != nil {
+		t.Fatalf("addToPath failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".config", "fish", "config.fish"))
+	if err != nil {
+		t.Fatalf("failed to read config.fish: %v", err)
+	}
+	if !strings.Contains(string(data), "fish_add_path "+dir) {
+		t.Fatalf("expected config.fish to call fish_add_path, got:\n%s", data)
+	}
+}
+
+func TestAddToPathPreservesExistingContent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/zsh")
+
+	rcPath := filepath.Join(home, ".zshrc")
+	if err := os.WriteFile(rcPath, []byte("export EDITOR=vim\n"), 0644); err != nil {
+		t.Fatalf("failed to seed .zshrc: %v", err)
+	}
+
+	dir := filepath.Join(home, ".local", "bin", "Antihub")
+	if err := addToPath(dir); err != nil {
+		t.Fatalf("addToPath failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(rcPath)
+	if !strings.Contains(string(data), "export EDITOR=vim") {
+		t.Fatalf("expected pre-existing .zshrc content to survive, got:\n%s", data)
+	}
+}
+
+func TestRemoveFromPathCleansUpMarkerBlock(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL", "/bin/bash")
+
+	dir := filepath.Join(home, ".local", "bin", "Antihub")
+	if err := addToPath(dir); err != nil {
+		t.Fatalf("addToPath failed: %v", err)
+	}
+
+	if err := removeFromPath(); err != nil {
+		t.Fatalf("removeFromPath failed: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if err != nil {
+		t.Fatalf("failed to read .bashrc: %v", err)
+	}
+	if strings.Contains(string(data), pathBlockStartMarker) {
+		t.Fatalf("expected marker block to be removed, got:\n%s", data)
+	}
+}
@@ -1,5 +1,5 @@
-//go:build !windows && !darwin
-// +build !windows,!darwin
+//go:build !windows && !darwin && !linux
+// +build !windows,!darwin,!linux
 
 package main
 
@@ -16,7 +16,11 @@ func addToPath(dir string) error {
 	return fmt.Errorf("add to PATH is not supported on this platform: %s", dir)
 }
 
+func openBrowser(url string) error {
+	return fmt.Errorf("opening a browser is not supported on this platform, please open manually: %s", url)
+}
+
 func recoverOriginal() error {
-	return fmt.Errorf("recover is only supported on Windows")
+	return fmt.Errorf("recover is not supported on this platform")
 }
 
@@ -0,0 +1,202 @@
+//go:build linux
+// +build linux
+
+package registry
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ProtocolName       = "kiro"
+	ProtocolScheme     = "kiro://"
+	AntiProtocolName   = "anti"
+	AntiProtocolScheme = "anti://"
+)
+
+type ProtocolHandler struct {
+	Protocol    string
+	ExePath     string
+	Description string
+}
+
+func NewProtocolHandler(protocol, description string) (*ProtocolHandler, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get executable path: %w", err)
+	}
+
+	exePath, err = filepath.Abs(exePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+	}
+
+	return &ProtocolHandler{
+		Protocol:    protocol,
+		ExePath:     exePath,
+		Description: description,
+	}, nil
+}
+
+// desktopFileName returns the basename antihook uses for this protocol's
+// .desktop file, e.g. "antihook-kiro.desktop".
+func (h *ProtocolHandler) desktopFileName() string {
+	return fmt.Sprintf("antihook-%s.desktop", h.Protocol)
+}
+
+func applicationsDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".local", "share", "applications"), nil
+}
+
+func (h *ProtocolHandler) desktopFilePath() (string, error) {
+	dir, err := applicationsDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, h.desktopFileName()), nil
+}
+
+func (h *ProtocolHandler) desktopFileContents() string {
+	return fmt.Sprintf(`[Desktop Entry]
+Type=Application
+Name=%s
+Exec=%s %%u
+Terminal=false
+NoDisplay=true
+MimeType=x-scheme-handler/%s;
+`, h.Description, h.ExePath, h.Protocol)
+}
+
+// Register writes a .desktop file for the protocol and asks xdg-mime to make
+// it the default handler for x-scheme-handler/<protocol>.
+func (h *ProtocolHandler) Register() error {
+	dir, err := applicationsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create applications directory: %w", err)
+	}
+
+	path, err := h.desktopFilePath()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(h.desktopFileContents()), 0644); err != nil {
+		return fmt.Errorf("failed to write desktop file: %w", err)
+	}
+
+	if _, err := exec.LookPath("update-desktop-database"); err == nil {
+		_ = exec.Command("update-desktop-database", dir).Run()
+	}
+
+	if _, err := exec.LookPath("xdg-mime"); err != nil {
+		return fmt.Errorf("xdg-mime not found on PATH: %w", err)
+	}
+
+	mimeType := fmt.Sprintf("x-scheme-handler/%s", h.Protocol)
+	cmd := exec.Command("xdg-mime", "default", h.desktopFileName(), mimeType)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("xdg-mime default failed: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// Unregister removes the desktop file antihook installed. It does not
+// attempt to restore whatever handler was previously registered; use
+// Backup/Restore for that.
+func (h *ProtocolHandler) Unregister() error {
+	path, err := h.desktopFilePath()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove desktop file: %w", err)
+	}
+
+	if dir, err := applicationsDir(); err == nil {
+		if _, err := exec.LookPath("update-desktop-database"); err == nil {
+			_ = exec.Command("update-desktop-database", dir).Run()
+		}
+	}
+
+	return nil
+}
+
+// GetRegisteredHandler returns the desktop file xdg-mime currently reports as
+// the default for this protocol's scheme, e.g. "antihook-kiro.desktop".
+func (h *ProtocolHandler) GetRegisteredHandler() (string, error) {
+	if _, err := exec.LookPath("xdg-mime"); err != nil {
+		return "", fmt.Errorf("xdg-mime not found on PATH: %w", err)
+	}
+
+	mimeType := fmt.Sprintf("x-scheme-handler/%s", h.Protocol)
+	out, err := exec.Command("xdg-mime", "query", "default", mimeType).Output()
+	if err != nil {
+		return "", fmt.Errorf("xdg-mime query failed: %w", err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+func (h *ProtocolHandler) IsRegistered() (bool, error) {
+	current, err := h.GetRegisteredHandler()
+	if err != nil {
+		return false, err
+	}
+	return current == h.desktopFileName(), nil
+}
+
+// IsSelfRegistered reports whether antihook is currently the registered
+// handler, treating query failures as "not registered" rather than an error.
+func (h *ProtocolHandler) IsSelfRegistered() (bool, error) {
+	current, err := h.GetRegisteredHandler()
+	if err != nil {
+		return false, nil
+	}
+	return current == h.desktopFileName(), nil
+}
+
+// Backup snapshots the currently registered default handler so it can be
+// restored later with Restore.
+func (h *ProtocolHandler) Backup() (map[string]string, error) {
+	current, err := h.GetRegisteredHandler()
+	if err != nil {
+		// Nothing registered yet (or xdg-mime unavailable) isn't fatal for a
+		// backup: an empty previous handler just means Restore clears ours.
+		current = ""
+	}
+	return map[string]string{"default": current}, nil
+}
+
+// Restore reinstates whatever default handler Backup captured. If the
+// backup recorded no previous handler, it removes our desktop file instead
+// of leaving antihook registered.
+func (h *ProtocolHandler) Restore(backup map[string]string) error {
+	previous := backup["default"]
+	if previous == "" {
+		return h.Unregister()
+	}
+
+	if _, err := exec.LookPath("xdg-mime"); err != nil {
+		return fmt.Errorf("xdg-mime not found on PATH: %w", err)
+	}
+
+	mimeType := fmt.Sprintf("x-scheme-handler/%s", h.Protocol)
+	cmd := exec.Command("xdg-mime", "default", previous, mimeType)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("xdg-mime default failed: %w (%s)", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
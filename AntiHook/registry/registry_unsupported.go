@@ -1,5 +1,5 @@
-//go:build !windows && !darwin
-// +build !windows,!darwin
+//go:build !windows && !darwin && !linux
+// +build !windows,!darwin,!linux
 
 package registry
 
@@ -10,8 +10,10 @@ import (
 )
 
 const (
-	ProtocolName   = "kiro"
-	ProtocolScheme = "kiro://"
+	ProtocolName       = "kiro"
+	ProtocolScheme     = "kiro://"
+	AntiProtocolName   = "anti"
+	AntiProtocolScheme = "anti://"
 )
 
 type ProtocolHandler struct {